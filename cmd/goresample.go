@@ -11,7 +11,9 @@
 package main
 
 import (
+	"bytes"
 	"flag"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -54,10 +56,8 @@ func main() {
 	}
 	inputFile := flag.Arg(0)
 	outputFile := flag.Arg(1)
-	var err error
-	var input []byte
 
-	input, err = ioutil.ReadFile(inputFile)
+	input, err := ioutil.ReadFile(inputFile)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -65,18 +65,29 @@ func main() {
 	if err != nil {
 		log.Fatalln(err)
 	}
-	// Create a Reampler
+	defer output.Close()
+
+	if strings.ToLower(filepath.Ext(inputFile)) == ".wav" {
+		// NewWAVReader parses the channels, sample rate and sample format
+		// out of the RIFF/WAVE header itself, so -ch, -ir and -format are
+		// only consulted for raw PCM input below.
+		reader, err := resample.NewWAVReader(bytes.NewReader(input), float64(*or), resample.HighQ)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer reader.Close()
+		if _, err = io.Copy(output, reader); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	// Create a Resampler
 	res, err := resample.New(output, float64(*ir), float64(*or), *ch, frmt, resample.HighQ)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	// Resample data and wrte to output file
-	if strings.ToLower(filepath.Ext(inputFile)) == ".wav" {
-		_, err = res.Write(input[44:]) // Skip WAV header
-	} else {
-		_, err = res.Write(input)
-	}
-	if err != nil {
+	if _, err = res.Write(input); err != nil {
 		log.Println(err)
 	}
 	res.Close()
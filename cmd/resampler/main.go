@@ -26,8 +26,6 @@ import (
 	"github.com/zaf/resample"
 )
 
-const wavHeader = 44
-
 var (
 	format = flag.String("format", "i16", "PCM format")
 	ch     = flag.Int("ch", 2, "Number of channels")
@@ -61,7 +59,6 @@ func main() {
 	}
 	inputFile := flag.Arg(0)
 	outputFile := flag.Arg(1)
-	var err error
 
 	// Open input file (WAV or RAW PCM)
 	input, err := os.Open(inputFile)
@@ -73,6 +70,27 @@ func main() {
 	if err != nil {
 		log.Fatalln(err)
 	}
+
+	if strings.ToLower(filepath.Ext(inputFile)) == ".wav" {
+		// NewWAVReader parses the channels, sample rate and sample format
+		// out of the RIFF/WAVE header itself, so -ch, -ir and -format are
+		// only consulted for raw PCM input below.
+		reader, err := resample.NewWAVReader(input, float64(*or), resample.HighQ)
+		if err != nil {
+			output.Close()
+			os.Remove(outputFile)
+			log.Fatalln(err)
+		}
+		_, err = io.Copy(output, reader)
+		reader.Close()
+		output.Close()
+		if err != nil {
+			os.Remove(outputFile)
+			log.Fatalln(err)
+		}
+		return
+	}
+
 	// Create a Resampler
 	res, err := resample.New(output, float64(*ir), float64(*or), *ch, frmt, resample.HighQ)
 	if err != nil {
@@ -80,10 +98,6 @@ func main() {
 		os.Remove(outputFile)
 		log.Fatalln(err)
 	}
-	// Skip WAV file header in order to pass only the PCM data to the Resampler
-	if strings.ToLower(filepath.Ext(inputFile)) == ".wav" {
-		input.Seek(wavHeader, 0)
-	}
 
 	// Read input and pass it to the Resampler in chunks
 	_, err = io.Copy(res, input)
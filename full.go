@@ -0,0 +1,232 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package resample
+
+/*
+#include <stdlib.h>
+#include <soxr.h>
+*/
+import "C"
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"unsafe"
+)
+
+// newSoxrFull is like newSoxr but allows the input and output sample
+// formats and channel counts to differ. soxr itself always resamples a
+// fixed number of channels at a fixed pair of datatypes, so it is created
+// with outChannels channels throughout; channel up/down-mixing happens in
+// Go, ahead of soxr, on the native inFormat samples.
+func newSoxrFull(inputRate, outputRate float64, inChannels, outChannels, inFormat, outFormat, quality int) (C.soxr_t, int, int, error) {
+	if inputRate <= 0 || outputRate <= 0 {
+		return nil, 0, 0, errors.New("invalid input or output sampling rates")
+	}
+	if inChannels == 0 || outChannels == 0 {
+		return nil, 0, 0, errors.New("invalid channels number")
+	}
+	if quality < 0 || quality > 6 {
+		return nil, 0, 0, errors.New("invalid quality setting")
+	}
+	inSize, err := formatSize(inFormat)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	outSize, err := formatSize(outFormat)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	var soxr C.soxr_t
+	var soxErr C.soxr_error_t
+	ioSpec := C.soxr_io_spec(C.soxr_datatype_t(inFormat), C.soxr_datatype_t(outFormat))
+	qSpec := C.soxr_quality_spec(C.ulong(quality), 0)
+	runtimeSpec := C.soxr_runtime_spec(C.uint(threads))
+	soxr = C.soxr_create(C.double(inputRate), C.double(outputRate), C.uint(outChannels), &soxErr, &ioSpec, &qSpec, &runtimeSpec)
+	defer C.free(unsafe.Pointer(soxErr))
+	if C.GoString(soxErr) != "" && C.GoString(soxErr) != "0" {
+		return nil, 0, 0, errors.New(C.GoString(soxErr))
+	}
+	return soxr, inSize, outSize, nil
+}
+
+// defaultMixMatrix returns the outChannels x inChannels channel mixing
+// matrix used by NewFull when no explicit coefficients are given: a
+// downmix partitions the input channels into outChannels contiguous
+// groups (as evenly sized as possible) and averages each group into its
+// corresponding output channel, e.g. stereo into mono averages L+R, and
+// quad into stereo keeps the front and rear pairs separate instead of
+// collapsing all four channels into an identical average on both output
+// channels. An upmix duplicates input channels across the extra output
+// channels (e.g. mono duplicated into L and R), and a same channel count
+// passes straight through.
+func defaultMixMatrix(inChannels, outChannels int) [][]float64 {
+	m := make([][]float64, outChannels)
+	for i := range m {
+		m[i] = make([]float64, inChannels)
+	}
+	switch {
+	case outChannels == inChannels:
+		for i := range m {
+			m[i][i] = 1
+		}
+	case outChannels < inChannels:
+		base := inChannels / outChannels
+		rem := inChannels % outChannels
+		start := 0
+		for i := range m {
+			size := base
+			if i < rem {
+				size++
+			}
+			w := 1 / float64(size)
+			for j := start; j < start+size; j++ {
+				m[i][j] = w
+			}
+			start += size
+		}
+	default:
+		for i := range m {
+			m[i][i%inChannels] = 1
+		}
+	}
+	return m
+}
+
+// NewFull returns a pointer to a Resampler that, unlike New, allows the
+// input and output sample formats and channel counts to differ, e.g.
+// taking I16 stereo in and emitting F32 mono out. Channel conversion is
+// performed in Go ahead of soxr using defaultMixMatrix; pass a custom
+// coeffs matrix (outChannels rows of inChannels weights each) to override
+// it, or nil to use the default.
+func NewFull(writer io.Writer, inputRate, outputRate float64, inChannels, outChannels, inFormat, outFormat, quality int, coeffs [][]float64) (*Resampler, error) {
+	if writer == nil {
+		return nil, errors.New("io.Writer is nil")
+	}
+	if coeffs == nil {
+		coeffs = defaultMixMatrix(inChannels, outChannels)
+	} else if len(coeffs) != outChannels {
+		return nil, errors.New("mixing matrix must have outChannels rows")
+	}
+	for _, row := range coeffs {
+		if len(row) != inChannels {
+			return nil, errors.New("mixing matrix rows must have inChannels columns")
+		}
+	}
+	soxr, inSize, outSize, err := newSoxrFull(inputRate, outputRate, inChannels, outChannels, inFormat, outFormat, quality)
+	if err != nil {
+		return nil, err
+	}
+	r := Resampler{
+		resampler:    soxr,
+		inRate:       inputRate,
+		outRate:      outputRate,
+		channels:     outChannels,
+		frameSize:    outSize,
+		destination:  writer,
+		fullMode:     true,
+		inFormat:     inFormat,
+		inChannels:   inChannels,
+		outChannels:  outChannels,
+		inFrameSize:  inSize,
+		outFrameSize: outSize,
+		mixCoeffs:    coeffs,
+	}
+	return &r, nil
+}
+
+// processFull is the NewFull counterpart of process: it remixes channels
+// ahead of soxr and accounts for input and output frames separately, since
+// they may differ in both channel count and sample size. The remixed
+// buffer is passed to soxr_process as soxr_in_t without copying; libsoxr
+// reads it synchronously and does not retain the pointer past the call.
+func (r *Resampler) processFull(p []byte) (int, error) {
+	if r.resampler == nil {
+		return 0, errors.New("soxr resampler is nil")
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	inFrameBytes := r.inFrameSize * r.inChannels
+	framesIn := len(p) / inFrameBytes
+	if framesIn == 0 {
+		return 0, errors.New("incomplete input frame data")
+	}
+	framesOut := int(float64(framesIn) * (r.outRate / r.inRate))
+	if framesOut == 0 {
+		return 0, errors.New("not enough input to generate output")
+	}
+	remixed := remixChannels(p, framesIn, r.inChannels, r.outChannels, r.inFrameSize, r.inFormat, r.mixCoeffs)
+	dataOut := r.outputBuffer(framesOut * r.outChannels * r.outFrameSize)
+	var soxErr C.soxr_error_t
+	var read, done C.size_t
+	soxErr = C.soxr_process(r.resampler, C.soxr_in_t(unsafe.Pointer(&remixed[0])), C.size_t(framesIn), &read,
+		C.soxr_out_t(dataOut), C.size_t(framesOut), &done)
+	defer C.free(unsafe.Pointer(soxErr))
+	if C.GoString(soxErr) != "" && C.GoString(soxErr) != "0" {
+		return 0, errors.New(C.GoString(soxErr))
+	}
+	written := int(done) * r.outChannels * r.outFrameSize
+	if _, err := r.destination.Write(unsafe.Slice((*byte)(dataOut), written)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// remixChannels applies coeffs (an outChannels x inChannels matrix) to
+// framesIn frames of inChannels audio in format, producing framesIn frames
+// of outChannels audio in the same format.
+func remixChannels(in []byte, framesIn, inChannels, outChannels, sampleSize, format int, coeffs [][]float64) []byte {
+	out := make([]byte, framesIn*outChannels*sampleSize)
+	for f := 0; f < framesIn; f++ {
+		inFrame := in[f*inChannels*sampleSize : (f+1)*inChannels*sampleSize]
+		outFrame := out[f*outChannels*sampleSize : (f+1)*outChannels*sampleSize]
+		for oc := 0; oc < outChannels; oc++ {
+			var v float64
+			for ic := 0; ic < inChannels; ic++ {
+				w := coeffs[oc][ic]
+				if w == 0 {
+					continue
+				}
+				v += w * readSample(inFrame[ic*sampleSize:], format)
+			}
+			writeSample(outFrame[oc*sampleSize:], format, v)
+		}
+	}
+	return out
+}
+
+// readSample reads a single sample of format from the start of b.
+func readSample(b []byte, format int) float64 {
+	switch format {
+	case F32:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case F64:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	case I32:
+		return float64(int32(binary.LittleEndian.Uint32(b)))
+	default: // I16
+		return float64(int16(binary.LittleEndian.Uint16(b)))
+	}
+}
+
+// writeSample writes a single sample of format to the start of b.
+func writeSample(b []byte, format int, v float64) {
+	switch format {
+	case F32:
+		binary.LittleEndian.PutUint32(b, math.Float32bits(float32(v)))
+	case F64:
+		binary.LittleEndian.PutUint64(b, math.Float64bits(v))
+	case I32:
+		binary.LittleEndian.PutUint32(b, uint32(int32(v)))
+	default: // I16
+		binary.LittleEndian.PutUint16(b, uint16(int16(v)))
+	}
+}
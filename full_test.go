@@ -0,0 +1,73 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package resample
+
+import (
+	"io"
+	"testing"
+)
+
+func TestNewFull(t *testing.T) {
+	_, err := NewFull(nil, 8000.0, 8000.0, 2, 1, I16, I16, MediumQ, nil)
+	if err == nil || err.Error() != "io.Writer is nil" {
+		t.Fatalf("Expecting: %s got: %v", "io.Writer is nil", err)
+	}
+	_, err = NewFull(io.Discard, 8000.0, 8000.0, 2, 1, I16, I16, MediumQ, [][]float64{{1, 0}, {0, 1}})
+	if err == nil || err.Error() != "mixing matrix must have outChannels rows" {
+		t.Fatalf("Expecting: %s got: %v", "mixing matrix must have outChannels rows", err)
+	}
+	_, err = NewFull(io.Discard, 8000.0, 8000.0, 2, 1, I16, I16, MediumQ, [][]float64{{1}})
+	if err == nil || err.Error() != "mixing matrix rows must have inChannels columns" {
+		t.Fatalf("Expecting: %s got: %v", "mixing matrix rows must have inChannels columns", err)
+	}
+	res, err := NewFull(io.Discard, 8000.0, 8000.0, 2, 1, I16, F32, MediumQ, nil)
+	if err != nil {
+		t.Fatal("Failed to create a Resampler via NewFull:", err)
+	}
+	res.Close()
+}
+
+func TestDefaultMixMatrix(t *testing.T) {
+	down := defaultMixMatrix(2, 1)
+	if len(down) != 1 || len(down[0]) != 2 || down[0][0] != 0.5 || down[0][1] != 0.5 {
+		t.Errorf("unexpected stereo->mono mix matrix: %v", down)
+	}
+	up := defaultMixMatrix(1, 2)
+	if len(up) != 2 || up[0][0] != 1 || up[1][0] != 1 {
+		t.Errorf("unexpected mono->stereo mix matrix: %v", up)
+	}
+	same := defaultMixMatrix(2, 2)
+	if same[0][0] != 1 || same[0][1] != 0 || same[1][0] != 0 || same[1][1] != 1 {
+		t.Errorf("unexpected passthrough mix matrix: %v", same)
+	}
+	quad := defaultMixMatrix(4, 2)
+	want := [][]float64{{0.5, 0.5, 0, 0}, {0, 0, 0.5, 0.5}}
+	for i := range want {
+		for j := range want[i] {
+			if quad[i][j] != want[i][j] {
+				t.Errorf("unexpected 4ch->2ch mix matrix: %v, expecting %v", quad, want)
+			}
+		}
+	}
+}
+
+func TestRemixChannels(t *testing.T) {
+	// Two I16 stereo frames: (100, 200), (300, 400).
+	in := []byte{100, 0, 200, 0, 44, 1, 144, 1}
+	out := remixChannels(in, 2, 2, 1, 2, I16, defaultMixMatrix(2, 1))
+	if len(out) != 4 {
+		t.Fatalf("remixChannels returned %d bytes, expecting 4", len(out))
+	}
+	if got := int16(out[0]) | int16(out[1])<<8; got != 150 {
+		t.Errorf("frame 1 downmix = %d, expecting 150", got)
+	}
+	if got := int16(out[2]) | int16(out[3])<<8; got != 350 {
+		t.Errorf("frame 2 downmix = %d, expecting 350", got)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package resample
+
+import (
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// formatPeak returns the full-scale amplitude for format, used to scale a
+// generated waveform into its native range.
+func formatPeak(format int) (float64, error) {
+	switch format {
+	case F32, F64:
+		return 1.0, nil
+	case I32:
+		return math.MaxInt32, nil
+	case I16:
+		return math.MaxInt16, nil
+	default:
+		return 0, errors.New("invalid format setting")
+	}
+}
+
+// GenerateSilence writes d of silence to w, encoded as channels-channel PCM
+// audio in format at the given sample rate. It is useful for padding, test
+// fixtures and gap filling without needing an actual Resampler.
+func GenerateSilence(w io.Writer, rate float64, channels, format int, d time.Duration) error {
+	if w == nil {
+		return errors.New("io.Writer is nil")
+	}
+	if rate <= 0 {
+		return errors.New("invalid sample rate")
+	}
+	if channels == 0 {
+		return errors.New("invalid channels number")
+	}
+	size, err := formatSize(format)
+	if err != nil {
+		return err
+	}
+	frames := int(d.Seconds() * rate)
+	buf := make([]byte, frames*channels*size)
+	_, err = w.Write(buf)
+	return err
+}
+
+// GenerateTone writes d of a phase-continuous sine wave at freq Hz to w,
+// encoded as channels-channel PCM audio in format at the given sample
+// rate. The waveform is duplicated across all channels.
+func GenerateTone(w io.Writer, rate float64, channels, format int, freq float64, d time.Duration) error {
+	if w == nil {
+		return errors.New("io.Writer is nil")
+	}
+	if rate <= 0 {
+		return errors.New("invalid sample rate")
+	}
+	if channels == 0 {
+		return errors.New("invalid channels number")
+	}
+	if freq <= 0 {
+		return errors.New("invalid tone frequency")
+	}
+	size, err := formatSize(format)
+	if err != nil {
+		return err
+	}
+	peak, err := formatPeak(format)
+	if err != nil {
+		return err
+	}
+	frames := int(d.Seconds() * rate)
+	buf := make([]byte, frames*channels*size)
+	step := 2 * math.Pi * freq / rate
+	for f := 0; f < frames; f++ {
+		v := peak * math.Sin(step*float64(f))
+		frame := buf[f*channels*size:]
+		for c := 0; c < channels; c++ {
+			writeSample(frame[c*size:], format, v)
+		}
+	}
+	_, err = w.Write(buf)
+	return err
+}
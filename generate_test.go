@@ -0,0 +1,91 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package resample
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGenerateSilence(t *testing.T) {
+	var buf bytes.Buffer
+	err := GenerateSilence(&buf, 8000.0, 2, I16, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal("Failed to generate silence:", err)
+	}
+	want := int(0.01*8000) * 2 * 2
+	if buf.Len() != want {
+		t.Errorf("got %d bytes, expecting %d", buf.Len(), want)
+	}
+	for _, b := range buf.Bytes() {
+		if b != 0 {
+			t.Fatal("silence buffer contains non-zero byte")
+		}
+	}
+}
+
+func TestGenerateSilenceErrors(t *testing.T) {
+	if err := GenerateSilence(nil, 8000.0, 2, I16, time.Second); err == nil {
+		t.Fatal("expecting error on nil writer")
+	}
+	var buf bytes.Buffer
+	if err := GenerateSilence(&buf, 0, 2, I16, time.Second); err == nil {
+		t.Fatal("expecting error on invalid sample rate")
+	}
+	if err := GenerateSilence(&buf, 8000.0, 0, I16, time.Second); err == nil {
+		t.Fatal("expecting error on invalid channels")
+	}
+	if err := GenerateSilence(&buf, 8000.0, 2, 99, time.Second); err == nil {
+		t.Fatal("expecting error on invalid format")
+	}
+}
+
+func TestGenerateTone(t *testing.T) {
+	var buf bytes.Buffer
+	rate := 8000.0
+	err := GenerateTone(&buf, rate, 1, I16, 440.0, 5*time.Millisecond)
+	if err != nil {
+		t.Fatal("Failed to generate tone:", err)
+	}
+	frames := int(0.005 * rate)
+	if buf.Len() != frames*2 {
+		t.Errorf("got %d bytes, expecting %d", buf.Len(), frames*2)
+	}
+	// The first sample is sin(0), so it should be exactly 0.
+	first := int16(binary.LittleEndian.Uint16(buf.Bytes()[0:2]))
+	if first != 0 {
+		t.Errorf("first sample = %d, expecting 0", first)
+	}
+}
+
+func TestGenerateToneErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := GenerateTone(nil, 8000.0, 1, I16, 440.0, time.Second); err == nil {
+		t.Fatal("expecting error on nil writer")
+	}
+	if err := GenerateTone(&buf, 8000.0, 1, I16, 0, time.Second); err == nil {
+		t.Fatal("expecting error on invalid frequency")
+	}
+	if err := GenerateTone(&buf, 8000.0, 1, 99, 440.0, time.Second); err == nil {
+		t.Fatal("expecting error on invalid format")
+	}
+}
+
+func TestFormatPeak(t *testing.T) {
+	peak, err := formatPeak(I16)
+	if err != nil || peak != math.MaxInt16 {
+		t.Errorf("got %v, %v, expecting %v, nil", peak, err, math.MaxInt16)
+	}
+	if _, err := formatPeak(99); err == nil {
+		t.Fatal("expecting error on invalid format")
+	}
+}
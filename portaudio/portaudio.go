@@ -0,0 +1,270 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+// Package portaudio wraps a resample.Resampler around live PortAudio
+// capture and playback streams, so microphone input can be resampled to a
+// target rate in real time, or PCM written at an arbitrary rate can be
+// played back at the output device's native rate.
+//
+// It requires github.com/gordonklaus/portaudio and the PortAudio library
+// it binds to.
+package portaudio
+
+import (
+	"io"
+	"sync"
+
+	pa "github.com/gordonklaus/portaudio"
+	"github.com/zaf/resample"
+)
+
+const (
+	channels        = 1    // mono capture/playback
+	framesPerBuffer = 1024 // PortAudio frames per callback, also the capacity in chunks of both queues
+	sampleSize      = 2    // bytes per I16 sample
+)
+
+// chanWriter is an io.Writer that copies and pushes each Write's payload
+// onto a channel, since soxr's output buffer is reused across calls. It is
+// used for playback, where Write runs on the caller's goroutine rather
+// than the PortAudio callback, so blocking on a full channel is acceptable
+// backpressure.
+type chanWriter chan<- []byte
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+	w <- b
+	return len(p), nil
+}
+
+// ringWriter is an io.Writer like chanWriter that never blocks: if the
+// channel is full it drops the oldest pending chunk to make room for the
+// new one. It is used for capture, where Write runs on the PortAudio
+// real-time callback and must not stall waiting for a slow consumer;
+// dropping the oldest audio bounds latency instead of causing xruns.
+type ringWriter chan []byte
+
+func (w ringWriter) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+	for {
+		select {
+		case w <- b:
+			return len(p), nil
+		default:
+		}
+		select {
+		case <-w:
+		default:
+		}
+	}
+}
+
+// Resampler wraps a resample.Resampler fed by a live PortAudio capture
+// stream.
+type Resampler struct {
+	*resample.Resampler
+	stream *pa.Stream
+	errMu  sync.Mutex
+	err    error // last error returned by Write from the capture callback
+}
+
+// setErr records err as the last error seen by the capture callback, for
+// Err to report. The callback runs on PortAudio's real-time thread and
+// cannot return errors synchronously to the caller.
+func (r *Resampler) setErr(err error) {
+	r.errMu.Lock()
+	r.err = err
+	r.errMu.Unlock()
+}
+
+// Err returns the last error encountered while resampling captured audio,
+// or nil if none has occurred. Since the capture callback runs on
+// PortAudio's real-time thread, it cannot surface Write errors directly;
+// callers that need to detect a stalled or failing capture should poll
+// Err alongside reading from the output channel.
+func (r *Resampler) Err() error {
+	r.errMu.Lock()
+	defer r.errMu.Unlock()
+	return r.err
+}
+
+// Close stops the capture stream, releases the resampler and terminates
+// the PortAudio session. Should always be called when finished capturing.
+func (r *Resampler) Close() error {
+	err := r.stream.Stop()
+	if cerr := r.stream.Close(); err == nil {
+		err = cerr
+	}
+	if rerr := r.Resampler.Close(); err == nil {
+		err = rerr
+	}
+	if terr := pa.Terminate(); err == nil {
+		err = terr
+	}
+	return err
+}
+
+// NewCaptureResampler opens the default PortAudio input device and
+// resamples its mono 16-bit PCM audio to outRate at the given quality,
+// delivering resampled chunks on the returned channel as they become
+// available. Call Close on the returned Resampler to stop capture and
+// release the stream.
+func NewCaptureResampler(outRate float64, quality int) (*Resampler, <-chan []byte, error) {
+	if err := pa.Initialize(); err != nil {
+		return nil, nil, err
+	}
+	dev, err := pa.DefaultInputDevice()
+	if err != nil {
+		pa.Terminate()
+		return nil, nil, err
+	}
+
+	out := make(chan []byte, framesPerBuffer)
+	res, err := resample.New(ringWriter(out), dev.DefaultSampleRate, outRate, channels, resample.I16, quality)
+	if err != nil {
+		pa.Terminate()
+		return nil, nil, err
+	}
+
+	r := &Resampler{Resampler: res}
+
+	// Scratch buffer reused across callbacks, sized to one PortAudio
+	// frames-per-buffer worth of samples. Capture latency is bounded by the
+	// out channel, which holds up to framesPerBuffer resampled chunks.
+	scratch := make([]byte, framesPerBuffer*channels*sampleSize)
+	stream, err := pa.OpenDefaultStream(channels, 0, dev.DefaultSampleRate, framesPerBuffer, func(in []int16) {
+		for i, s := range in {
+			scratch[i*sampleSize] = byte(s)
+			scratch[i*sampleSize+1] = byte(s >> 8)
+		}
+		if _, err := res.Write(scratch[:len(in)*sampleSize]); err != nil {
+			r.setErr(err)
+		}
+	})
+	if err != nil {
+		res.Close()
+		pa.Terminate()
+		return nil, nil, err
+	}
+	if err := stream.Start(); err != nil {
+		stream.Close()
+		res.Close()
+		pa.Terminate()
+		return nil, nil, err
+	}
+	r.stream = stream
+	return r, out, nil
+}
+
+// playback is the io.WriteCloser returned by NewPlaybackResampler. Writes
+// go through the embedded resampler, which hands resampled chunks to the
+// playback callback over a bounded queue.
+type playback struct {
+	*resample.Resampler
+	stream *pa.Stream
+	queue  chan []byte
+	left   []byte // undrained remainder of the chunk currently being played
+}
+
+// fill drains queued, resampled PCM into out, padding with silence once the
+// queue runs dry.
+func (p *playback) fill(out []int16) {
+	need := len(out) * sampleSize
+	buf := make([]byte, 0, need)
+	for len(buf) < need {
+		if len(p.left) == 0 {
+			select {
+			case chunk, ok := <-p.queue:
+				if ok {
+					p.left = chunk
+				}
+			default:
+			}
+		}
+		if len(p.left) == 0 {
+			break
+		}
+		n := need - len(buf)
+		if n > len(p.left) {
+			n = len(p.left)
+		}
+		buf = append(buf, p.left[:n]...)
+		p.left = p.left[n:]
+	}
+	for i := range out {
+		if i*sampleSize+1 < len(buf) {
+			out[i] = int16(buf[i*sampleSize]) | int16(buf[i*sampleSize+1])<<8
+		} else {
+			out[i] = 0
+		}
+	}
+}
+
+// Close stops the playback stream, releases the resampler and terminates
+// the PortAudio session. Should always be called when finished playing.
+//
+// The resampler is flushed before the stream is stopped: Resampler.Close
+// pushes any trailing output onto p.queue through the blocking chanWriter,
+// and that send can only complete while fill is still draining the queue
+// on the live callback. Stopping the stream first would leave nothing to
+// drain it and could hang Close forever.
+func (p *playback) Close() error {
+	err := p.Resampler.Close()
+	if serr := p.stream.Stop(); err == nil {
+		err = serr
+	}
+	if cerr := p.stream.Close(); err == nil {
+		err = cerr
+	}
+	close(p.queue)
+	if terr := pa.Terminate(); err == nil {
+		err = terr
+	}
+	return err
+}
+
+// NewPlaybackResampler accepts PCM writes at inRate and plays them back
+// through the default PortAudio output device at its native sample rate.
+// Writes resample and enqueue PCM for the playback callback to drain. The
+// queue holds up to framesPerBuffer resampled chunks, one per Write, and
+// Write blocks while it is full.
+func NewPlaybackResampler(inRate float64, quality int) (io.WriteCloser, error) {
+	if err := pa.Initialize(); err != nil {
+		return nil, err
+	}
+	dev, err := pa.DefaultOutputDevice()
+	if err != nil {
+		pa.Terminate()
+		return nil, err
+	}
+
+	p := &playback{queue: make(chan []byte, framesPerBuffer)}
+	res, err := resample.New(chanWriter(p.queue), inRate, dev.DefaultSampleRate, channels, resample.I16, quality)
+	if err != nil {
+		pa.Terminate()
+		return nil, err
+	}
+	p.Resampler = res
+
+	stream, err := pa.OpenDefaultStream(0, channels, dev.DefaultSampleRate, framesPerBuffer, p.fill)
+	if err != nil {
+		res.Close()
+		pa.Terminate()
+		return nil, err
+	}
+	if err := stream.Start(); err != nil {
+		stream.Close()
+		res.Close()
+		pa.Terminate()
+		return nil, err
+	}
+	p.stream = stream
+	return p, nil
+}
@@ -0,0 +1,165 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package resample
+
+/*
+#include <stdlib.h>
+#include <soxr.h>
+*/
+import "C"
+import (
+	"errors"
+	"io"
+	"math"
+	"unsafe"
+)
+
+// ResamplerReader resamples PCM sound data pulled from an upstream
+// io.Reader. It implements io.ReadCloser, so a resampler can be chained
+// directly after a decoder without an intermediate goroutine and io.Pipe.
+type ResamplerReader struct {
+	resampler C.soxr_t
+	inRate    float64   // input sample rate
+	outRate   float64   // output sample rate
+	channels  int       // number of input channels
+	frameSize int       // sample size in bytes
+	source    io.Reader // input data
+	scratch   []byte    // input frames pulled from source, grown as needed
+	valid     int       // bytes at the start of scratch not yet consumed by soxr
+	eof       bool      // source has been fully drained
+}
+
+// NewReader returns a pointer to a ResamplerReader that implements an
+// io.Reader. It takes as parameters the source data Reader, the input and
+// output sampling rates, the number of channels of the input data, the
+// input format and the quality setting.
+func NewReader(src io.Reader, inputRate, outputRate float64, channels, format, quality int) (*ResamplerReader, error) {
+	if src == nil {
+		return nil, errors.New("io.Reader is nil")
+	}
+	soxr, size, err := newSoxr(inputRate, outputRate, channels, format, quality)
+	if err != nil {
+		return nil, err
+	}
+	r := ResamplerReader{
+		resampler: soxr,
+		inRate:    inputRate,
+		outRate:   outputRate,
+		channels:  channels,
+		frameSize: size,
+		source:    src,
+	}
+	return &r, nil
+}
+
+// Read resamples PCM sound data pulled from the source Reader into p. It
+// returns the number of resampled bytes written to p (0 <= n <= len(p)) and
+// any error encountered. Once the source is drained, Read keeps returning
+// trailing output flushed from the resampler until it is exhausted, then
+// returns io.EOF.
+func (r *ResamplerReader) Read(p []byte) (int, error) {
+	if r.resampler == nil {
+		return 0, errors.New("soxr resampler is nil")
+	}
+	frameBytes := r.frameSize * r.channels
+	framesOut := len(p) / frameBytes
+	if framesOut == 0 {
+		return 0, errors.New("output buffer too small for a single frame")
+	}
+	for {
+		if r.valid == 0 && r.eof {
+			_, n, err := r.process(nil, 0, p, framesOut)
+			return n, err
+		}
+		if err := r.fill(framesOut, frameBytes); err != nil {
+			return 0, err
+		}
+		framesIn := r.valid / frameBytes
+		consumed, n, err := r.process(r.scratch[:framesIn*frameBytes], framesIn, p, framesOut)
+		consumedBytes := consumed * frameBytes
+		if consumedBytes > 0 {
+			copy(r.scratch, r.scratch[consumedBytes:r.valid])
+			r.valid -= consumedBytes
+		}
+		if err != nil || n > 0 {
+			return n, err
+		}
+		// soxr consumed input but hasn't produced an output frame yet
+		// (e.g. filter latency): pull more and try again.
+	}
+}
+
+// fill tops up r.scratch with newly read input so it holds enough unconsumed
+// frames to attempt filling framesOut output frames, unless the source is
+// already exhausted. Bytes already pulled but not yet consumed by soxr
+// (r.valid) are preserved at the front of the buffer.
+func (r *ResamplerReader) fill(framesOut, frameBytes int) error {
+	if r.eof {
+		return nil
+	}
+	wantFrames := int(math.Ceil(float64(framesOut) * r.inRate / r.outRate))
+	if wantFrames < 1 {
+		wantFrames = 1
+	}
+	wantBytes := wantFrames * frameBytes
+	if r.valid >= wantBytes {
+		return nil
+	}
+	need := wantBytes - r.valid
+	if cap(r.scratch) < r.valid+need {
+		grown := make([]byte, r.valid, r.valid+need)
+		copy(grown, r.scratch[:r.valid])
+		r.scratch = grown
+	}
+	r.scratch = r.scratch[:r.valid+need]
+	n, err := io.ReadFull(r.source, r.scratch[r.valid:r.valid+need])
+	r.valid += n
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		r.eof = true
+	}
+	return nil
+}
+
+// process feeds up to framesIn frames from in into soxr and writes any
+// resampled frames directly into p. It returns the number of input frames
+// soxr actually consumed (which may be fewer than framesIn, per libsoxr's
+// contract of consuming only as much input as needed to fill framesOut
+// output frames) along with the number of bytes written to p.
+func (r *ResamplerReader) process(in []byte, framesIn int, p []byte, framesOut int) (consumed, n int, err error) {
+	var inPtr C.soxr_in_t
+	if framesIn > 0 {
+		inPtr = C.soxr_in_t(unsafe.Pointer(&in[0]))
+	}
+	var soxErr C.soxr_error_t
+	var read, done C.size_t
+	soxErr = C.soxr_process(r.resampler, inPtr, C.size_t(framesIn), &read,
+		C.soxr_out_t(unsafe.Pointer(&p[0])), C.size_t(framesOut), &done)
+	defer C.free(unsafe.Pointer(soxErr))
+	if C.GoString(soxErr) != "" && C.GoString(soxErr) != "0" {
+		return 0, 0, errors.New(C.GoString(soxErr))
+	}
+	if done == 0 && framesIn == 0 && r.eof {
+		return int(read), 0, io.EOF
+	}
+	return int(read), int(done) * r.frameSize * r.channels, nil
+}
+
+// Close releases the resampler. It should always be called when finished
+// using the ResamplerReader.
+func (r *ResamplerReader) Close() error {
+	if r.resampler == nil {
+		return errors.New("soxr resampler is nil")
+	}
+	C.soxr_delete(r.resampler)
+	r.resampler = nil
+	return nil
+}
@@ -60,6 +60,22 @@ type Resampler struct {
 	channels    int       // number of input channels
 	frameSize   int       // frame size in bytes
 	destination io.Writer // output data
+	quality     int       // quality setting, kept for constructors that defer soxr creation
+	wavMode     bool      // true when created via NewWAV, input is a RIFF/WAVE stream
+	wavParsed   bool      // true once the RIFF/WAVE header has been consumed
+	wavHeader   []byte    // buffers input until the RIFF/WAVE header is fully read
+	wavBytes    int64     // bytes of PCM data written to destination, for size back-patching
+
+	fullMode     bool        // true when created via NewFull, input/output formats and channels may differ
+	inFormat     int         // input format, used for channel remapping ahead of soxr
+	inChannels   int         // number of input channels, may differ from the output channels
+	outChannels  int         // number of output channels, may differ from the input channels
+	inFrameSize  int         // input sample size in bytes
+	outFrameSize int         // output sample size in bytes
+	mixCoeffs    [][]float64 // outChannels x inChannels channel mixing matrix
+
+	outBuf    unsafe.Pointer // persistent C output scratch buffer, reused and grown across Write calls
+	outBufCap int            // capacity of outBuf in bytes
 }
 
 var threads int
@@ -68,34 +84,37 @@ func init() {
 	threads = runtime.NumCPU()
 }
 
-// New returns a pointer to a Resampler that implements an io.WriteCloser.
-// It takes as parameters the destination data Writer, the input and output
-// sampling rates, the number of channels of the input data, the input format
-// and the quality setting.
-func New(writer io.Writer, inputRate, outputRate float64, channels, format, quality int) (*Resampler, error) {
-	var err error
-	var size int
-	if writer == nil {
-		return nil, errors.New("io.Writer is nil")
+// formatSize returns the per-sample byte size of format.
+func formatSize(format int) (int, error) {
+	switch format {
+	case F64:
+		return 64 / byteLen, nil
+	case F32, I32:
+		return 32 / byteLen, nil
+	case I16:
+		return 16 / byteLen, nil
+	default:
+		return 0, errors.New("invalid format setting")
 	}
+}
+
+// newSoxr validates the resampling parameters and creates a new soxr_t
+// instance, returning it along with the per-sample byte size for format.
+// It is shared by New, NewReader and the WAV constructors; NewFull uses
+// newSoxrFull instead.
+func newSoxr(inputRate, outputRate float64, channels, format, quality int) (C.soxr_t, int, error) {
 	if inputRate <= 0 || outputRate <= 0 {
-		return nil, errors.New("invalid input or output sampling rates")
+		return nil, 0, errors.New("invalid input or output sampling rates")
 	}
 	if channels == 0 {
-		return nil, errors.New("invalid channels number")
+		return nil, 0, errors.New("invalid channels number")
 	}
 	if quality < 0 || quality > 6 {
-		return nil, errors.New("invalid quality setting")
+		return nil, 0, errors.New("invalid quality setting")
 	}
-	switch format {
-	case F64:
-		size = 64 / byteLen
-	case F32, I32:
-		size = 32 / byteLen
-	case I16:
-		size = 16 / byteLen
-	default:
-		return nil, errors.New("invalid format setting")
+	size, err := formatSize(format)
+	if err != nil {
+		return nil, 0, err
 	}
 	var soxr C.soxr_t
 	var soxErr C.soxr_error_t
@@ -104,12 +123,25 @@ func New(writer io.Writer, inputRate, outputRate float64, channels, format, qual
 	qSpec := C.soxr_quality_spec(C.ulong(quality), 0)
 	runtimeSpec := C.soxr_runtime_spec(C.uint(threads))
 	soxr = C.soxr_create(C.double(inputRate), C.double(outputRate), C.uint(channels), &soxErr, &ioSpec, &qSpec, &runtimeSpec)
+	defer C.free(unsafe.Pointer(soxErr))
 	if C.GoString(soxErr) != "" && C.GoString(soxErr) != "0" {
-		err = errors.New(C.GoString(soxErr))
-		C.free(unsafe.Pointer(soxErr))
-		return nil, err
+		return nil, 0, errors.New(C.GoString(soxErr))
 	}
+	return soxr, size, nil
+}
 
+// New returns a pointer to a Resampler that implements an io.WriteCloser.
+// It takes as parameters the destination data Writer, the input and output
+// sampling rates, the number of channels of the input data, the input format
+// and the quality setting.
+func New(writer io.Writer, inputRate, outputRate float64, channels, format, quality int) (*Resampler, error) {
+	if writer == nil {
+		return nil, errors.New("io.Writer is nil")
+	}
+	soxr, size, err := newSoxr(inputRate, outputRate, channels, format, quality)
+	if err != nil {
+		return nil, err
+	}
 	r := Resampler{
 		resampler:   soxr,
 		inRate:      inputRate,
@@ -118,8 +150,7 @@ func New(writer io.Writer, inputRate, outputRate float64, channels, format, qual
 		frameSize:   size,
 		destination: writer,
 	}
-	C.free(unsafe.Pointer(soxErr))
-	return &r, err
+	return &r, nil
 }
 
 // Reset permits reusing a Resampler rather than allocating a new one.
@@ -134,6 +165,30 @@ func (r *Resampler) Reset(writer io.Writer) error {
 	return err
 }
 
+// SetRatio changes the output sample rate of an already-running Resampler
+// in place, ramping to the new input/output ratio over transitionFrames
+// output frames rather than recreating the resampler. This is useful for
+// smooth pitch bends and for correcting clock drift between a capture and
+// a playback device running at nominally the same rate.
+func (r *Resampler) SetRatio(newOutRate float64, transitionFrames int) error {
+	if r.resampler == nil {
+		return errors.New("soxr resampler is nil")
+	}
+	if newOutRate <= 0 {
+		return errors.New("invalid output sampling rate")
+	}
+	if transitionFrames < 0 {
+		return errors.New("invalid transition frames")
+	}
+	soxErr := C.soxr_set_io_ratio(r.resampler, C.double(r.inRate/newOutRate), C.size_t(transitionFrames))
+	defer C.free(unsafe.Pointer(soxErr))
+	if C.GoString(soxErr) != "" && C.GoString(soxErr) != "0" {
+		return errors.New(C.GoString(soxErr))
+	}
+	r.outRate = newOutRate
+	return nil
+}
+
 // Close flushes, clean-ups and frees memory. Should always be called when
 // finished using the resampler. Should always be called when finished using
 // the resampler, and before we can use its output.
@@ -145,68 +200,107 @@ func (r *Resampler) Close() error {
 	err = r.flush()
 	C.soxr_delete(r.resampler)
 	r.resampler = nil
+	if r.outBuf != nil {
+		C.free(r.outBuf)
+		r.outBuf = nil
+		r.outBufCap = 0
+	}
+	if err == nil && r.wavMode && r.wavParsed {
+		if seeker, ok := r.destination.(io.WriteSeeker); ok {
+			err = patchWAVSizes(seeker, r.wavBytes)
+		}
+	}
 	return err
 }
 
 // Write resamples PCM sound data. Writes len(p) bytes from p to
 // the underlying data stream, returns the number of bytes written
 // from p (0 <= n <= len(p)) and any error encountered that caused
-// the write to stop early.
+// the write to stop early. A Resampler created with NewWAV buffers and
+// parses the RIFF/WAVE header before any PCM data reaches the resampler.
+//
+// Except for a Resampler created with NewFull, which first remixes p into
+// a new buffer, p is passed to libsoxr without copying, so the caller must not modify
+// it concurrently with Write; libsoxr does not retain the pointer past
+// the call, so p is free to reuse once Write returns.
 func (r *Resampler) Write(p []byte) (int, error) {
-	var err error
-	var i int
+	if r.wavMode && !r.wavParsed {
+		return r.writeWAVData(p)
+	}
+	if r.fullMode {
+		return r.processFull(p)
+	}
+	return r.process(p)
+}
+
+// outputBuffer returns a C-allocated scratch buffer of at least n bytes,
+// growing and reusing r.outBuf across calls so Write and flush don't pay
+// a malloc/free per call. The buffer is owned by r and freed in Close.
+func (r *Resampler) outputBuffer(n int) unsafe.Pointer {
+	if n > r.outBufCap {
+		r.outBuf = C.realloc(r.outBuf, C.size_t(n))
+		r.outBufCap = n
+	}
+	return r.outBuf
+}
+
+// process feeds p through soxr and writes the resampled output to
+// r.destination. p is passed straight to soxr_process as soxr_in_t without
+// copying; libsoxr reads it synchronously and does not retain the pointer
+// past the call.
+func (r *Resampler) process(p []byte) (int, error) {
 	if r.resampler == nil {
-		return i, errors.New("soxr resampler is nil")
+		return 0, errors.New("soxr resampler is nil")
 	}
 	if len(p) == 0 {
-		return i, nil
+		return 0, nil
 	}
 	framesIn := len(p) / r.frameSize / r.channels
 	if framesIn == 0 {
-		return i, errors.New("incomplete input frame data")
+		return 0, errors.New("incomplete input frame data")
 	}
 	framesOut := int(float64(framesIn) * (r.outRate / r.inRate))
 	if framesOut == 0 {
-		return i, errors.New("not enough input to generate output")
+		return 0, errors.New("not enough input to generate output")
 	}
-	dataIn := C.CBytes(p)
-	dataOut := C.malloc(C.size_t(framesOut * r.channels * r.frameSize))
+	dataOut := r.outputBuffer(framesOut * r.channels * r.frameSize)
 	var soxErr C.soxr_error_t
-	var read, done C.size_t = 0, 0
-	soxErr = C.soxr_process(r.resampler, C.soxr_in_t(dataIn), C.size_t(framesIn), &read, C.soxr_out_t(dataOut), C.size_t(framesOut), &done)
+	var read, done C.size_t
+	soxErr = C.soxr_process(r.resampler, C.soxr_in_t(unsafe.Pointer(&p[0])), C.size_t(framesIn), &read,
+		C.soxr_out_t(dataOut), C.size_t(framesOut), &done)
+	defer C.free(unsafe.Pointer(soxErr))
 	if C.GoString(soxErr) != "" && C.GoString(soxErr) != "0" {
-		err = errors.New(C.GoString(soxErr))
-		goto cleanup
+		return 0, errors.New(C.GoString(soxErr))
+	}
+	written := int(done) * r.channels * r.frameSize
+	_, err := r.destination.Write(unsafe.Slice((*byte)(dataOut), written))
+	if err != nil {
+		return 0, err
+	}
+	if r.wavMode {
+		r.wavBytes += int64(written)
 	}
-	_, err = r.destination.Write(C.GoBytes(dataOut, C.int(int(done)*r.channels*r.frameSize)))
 	// In many cases the resampler will not return the full data unless we flush it. Espasially if the input chunck is small
 	// As long as we close the resampler (Close() flushes all data) we don't need to worry about short writes, unless r.destination.Write() fails
-	if err == nil {
-		i = len(p)
-	}
-cleanup:
-	C.free(dataIn)
-	C.free(dataOut)
-	C.free(unsafe.Pointer(soxErr))
-	return i, err
+	return len(p), nil
 }
 
 // flush any pending output from the resampler. Aftter that no more input can be passed.
 func (r *Resampler) flush() error {
-	var err error
+	framesOut := 4096 * 16
+	dataOut := r.outputBuffer(framesOut * r.channels * r.frameSize)
 	var done C.size_t
 	var soxErr C.soxr_error_t
-	framesOut := 4096 * 16
-	dataOut := C.malloc(C.size_t(framesOut * r.channels * r.frameSize))
 	// Flush any pending output by calling soxr_process with no input data.
 	soxErr = C.soxr_process(r.resampler, nil, 0, nil, C.soxr_out_t(dataOut), C.size_t(framesOut), &done)
+	defer C.free(unsafe.Pointer(soxErr))
 	if C.GoString(soxErr) != "" && C.GoString(soxErr) != "0" {
-		err = errors.New(C.GoString(soxErr))
-		goto cleanup
+		return errors.New(C.GoString(soxErr))
+	}
+	written := int(done) * r.channels * r.frameSize
+	_, err := r.destination.Write(unsafe.Slice((*byte)(dataOut), written))
+	if err == nil && r.wavMode {
+		r.wavBytes += int64(written)
 	}
-	_, err = r.destination.Write(C.GoBytes(dataOut, C.int(int(done)*r.channels*r.frameSize)))
-cleanup:
-	C.free(dataOut)
-	C.free(unsafe.Pointer(soxErr))
 	return err
 }
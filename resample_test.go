@@ -57,6 +57,85 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewReader(t *testing.T) {
+	res, err := NewReader(bytes.NewReader(nil), 16000.0, 8000.0, 1, I16, MediumQ)
+	if err != nil {
+		t.Fatal("Failed to create a ResamplerReader:", err)
+	}
+	err = res.Close()
+	if err != nil {
+		t.Fatal("Failed to close ResamplerReader:", err)
+	}
+	err = res.Close()
+	if err == nil {
+		t.Fatal("Running Close on a closed ResamplerReader didn't return an error.")
+	}
+	_, err = NewReader(nil, 16000.0, 8000.0, 1, I16, MediumQ)
+	if err == nil || err.Error() != "io.Reader is nil" {
+		t.Fatalf("Expecting: %s got: %v", "io.Reader is nil", err)
+	}
+	_, err = NewReader(bytes.NewReader(nil), 16000.0, 8000.0, 0, I16, MediumQ)
+	if err == nil || err.Error() != "invalid channels number" {
+		t.Fatalf("Expecting: %s got: %v", "invalid channels number", err)
+	}
+}
+
+func TestResamplerReaderRead(t *testing.T) {
+	in := []byte{0x01, 0x00, 0x7c, 0x7f, 0xd1, 0xd0, 0xd3, 0xd2, 0xdd, 0xdc, 0xdf, 0xde}
+	res, err := NewReader(bytes.NewReader(in), 8000.0, 8000.0, 1, I16, MediumQ)
+	if err != nil {
+		t.Fatal("Failed to create a ResamplerReader:", err)
+	}
+	defer res.Close()
+	var out bytes.Buffer
+	buf := make([]byte, 2)
+	for {
+		n, err := res.Read(buf)
+		out.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Read failed:", err)
+		}
+	}
+	if out.Len() != len(in) {
+		t.Errorf("ResamplerReader Read returned %d bytes, expecting %d", out.Len(), len(in))
+	}
+}
+
+// TestResamplerReaderRateConversion exercises ResamplerReader with differing
+// input and output rates and a small output buffer, so each Read pulls far
+// fewer input frames than a full scratch refill and soxr is expected to
+// leave some of them unconsumed (consumed < framesIn). This is the
+// retained-scratch/compaction path in fill and Read.
+func TestResamplerReaderRateConversion(t *testing.T) {
+	in := []byte{0x01, 0x00, 0x7c, 0x7f, 0xd1, 0xd0, 0xd3, 0xd2, 0xdd, 0xdc, 0xdf, 0xde}
+	const inRate, outRate = 8000.0, 16000.0
+	res, err := NewReader(bytes.NewReader(in), inRate, outRate, 1, I16, MediumQ)
+	if err != nil {
+		t.Fatal("Failed to create a ResamplerReader:", err)
+	}
+	defer res.Close()
+	var out bytes.Buffer
+	buf := make([]byte, 2) // one frame at a time
+	for {
+		n, err := res.Read(buf)
+		out.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Read failed:", err)
+		}
+	}
+	inSize := float64(len(in))
+	outSize := float64(out.Len()) * inRate / outRate
+	if inSize != outSize {
+		t.Errorf("ResamplerReader Read returned %d bytes, expecting %d", out.Len(), int(len(in)*int(outRate/inRate)))
+	}
+}
+
 var WriteTest = []struct {
 	name       string
 	inputRate  float64
@@ -146,12 +225,16 @@ func TestFile(t *testing.T) {
 		if err != nil {
 			t.Fatal("Failed to read test data:", err)
 		}
+		_, offset, ok, err := parseWAVChunks(input)
+		if err != nil || !ok {
+			t.Fatalf("failed to locate the data chunk in %s: ok=%v err=%v", td.file, ok, err)
+		}
 		var out bytes.Buffer
 		res, err := New(&out, td.inputRate, td.outputRate, td.channels, td.format, td.quality)
 		if err != nil {
 			t.Fatal("Failed to create a Resampler:", err)
 		}
-		_, err = res.Write(input[44:])
+		_, err = res.Write(input[offset:])
 		if err != nil {
 			t.Errorf("Write failed: %s", err)
 		}
@@ -159,7 +242,7 @@ func TestFile(t *testing.T) {
 		if err != nil {
 			t.Fatal("Failed to close Resampler:", err)
 		}
-		inSize := float64(len(input[44:]))
+		inSize := float64(len(input[offset:]))
 		outSize := float64(out.Len()) * td.inputRate / td.outputRate
 		if inSize != outSize {
 			t.Error("Resampled file size mismatch, in:", int(inSize), "out:", int(outSize))
@@ -205,6 +288,37 @@ func TestReset(t *testing.T) {
 	}
 }
 
+func TestSetRatio(t *testing.T) {
+	res, err := New(io.Discard, 16000.0, 8000.0, 1, I16, MediumQ)
+	if err != nil {
+		t.Fatal("Failed to create a Resampler:", err)
+	}
+	err = res.SetRatio(11025.0, 1024)
+	if err != nil {
+		t.Fatal("Failed to SetRatio on the Resampler:", err)
+	}
+	_, err = res.Write(WriteTest[0].testData[3].data)
+	if err != nil {
+		t.Error("Write after SetRatio failed:", err)
+	}
+	err = res.SetRatio(0, 1024)
+	if err == nil || err.Error() != "invalid output sampling rate" {
+		t.Fatalf("Expecting: %s got: %v", "invalid output sampling rate", err)
+	}
+	err = res.SetRatio(8000.0, -1)
+	if err == nil || err.Error() != "invalid transition frames" {
+		t.Fatalf("Expecting: %s got: %v", "invalid transition frames", err)
+	}
+	err = res.Close()
+	if err != nil {
+		t.Fatal("Failed to close Resampler:", err)
+	}
+	err = res.SetRatio(8000.0, 0)
+	if err == nil {
+		t.Fatal("Running SetRatio on a closed Resampler didn't return an error.")
+	}
+}
+
 // Benchmarking data
 var BenchData = []struct {
 	name     string
@@ -239,6 +353,7 @@ func BenchmarkResampling(b *testing.B) {
 			if err != nil {
 				b.Fatalf("Failed to create Writer: %s\n", err)
 			}
+			b.ReportAllocs()
 			b.ResetTimer()
 			for i := 0; i < b.N; i++ {
 				_, err = res.Write(rawData[44:])
@@ -250,3 +365,45 @@ func BenchmarkResampling(b *testing.B) {
 		})
 	}
 }
+
+// BenchmarkResamplingSmallChunks writes each file in small streaming-sized
+// chunks rather than all at once, the pattern that used to pay a CBytes/
+// GoBytes copy per Write and benefits most from reusing a scratch buffer.
+func BenchmarkResamplingSmallChunks(b *testing.B) {
+	const framesPerChunk = 512
+	for _, bd := range BenchData {
+		b.Run(bd.name, func(b *testing.B) {
+			rawData, err := os.ReadFile(bd.file)
+			if err != nil {
+				b.Fatalf("Failed to read test data: %s\n", err)
+			}
+			data := rawData[44:]
+			size, err := formatSize(bd.format)
+			if err != nil {
+				b.Fatal(err)
+			}
+			frameBytes := size * bd.channels
+			chunk := framesPerChunk * frameBytes
+			data = data[:len(data)-len(data)%chunk]
+			b.SetBytes(int64(len(data)))
+			res, err := New(io.Discard, bd.inRate, bd.outRate, bd.channels, bd.format, bd.quality)
+			if err != nil {
+				b.Fatalf("Failed to create Writer: %s\n", err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for off := 0; off < len(data); off += chunk {
+					end := off + chunk
+					if end > len(data) {
+						end = len(data)
+					}
+					if _, err = res.Write(data[off:end]); err != nil {
+						b.Fatalf("Encoding failed: %s\n", err)
+					}
+				}
+			}
+			res.Close()
+		})
+	}
+}
@@ -0,0 +1,258 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package resample
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// WAV/RIFF constants. Chunk IDs are four ASCII bytes, chunk sizes are
+// little-endian uint32s, and chunks are padded to an even number of bytes.
+const (
+	riffHeaderSize = 12 // "RIFF" + size(4) + "WAVE"
+	fmtChunkSize   = 16 // size of a canonical (non-extensible) "fmt " chunk body
+	wavHeaderSize  = riffHeaderSize + 8 + fmtChunkSize + 8
+
+	wavFormatPCM       = 1
+	wavFormatIEEEFloat = 3
+)
+
+// wavFormat holds the fields parsed out of a WAV file's "fmt " chunk.
+type wavFormat struct {
+	audioFormat   uint16
+	channels      uint16
+	sampleRate    uint32
+	bitsPerSample uint16
+}
+
+// NewWAV returns a pointer to a Resampler that implements an io.WriteCloser.
+// Unlike New, it does not need to be told the input channels, sample rate
+// or sample format: it auto-detects them by parsing the RIFF/WAVE header
+// out of the first bytes written to it, scanning chunks instead of
+// assuming "fmt " and "data" sit at fixed offsets. Once the header is
+// parsed it writes a matching WAV header for the resampled output to
+// writer, then streams resampled PCM data as it is written. If writer
+// implements io.WriteSeeker the RIFF and data chunk sizes are back-patched
+// on Close, otherwise they are left as zero.
+func NewWAV(writer io.Writer, outputRate float64, quality int) (*Resampler, error) {
+	if writer == nil {
+		return nil, errors.New("io.Writer is nil")
+	}
+	if outputRate <= 0 {
+		return nil, errors.New("invalid output sampling rate")
+	}
+	if quality < 0 || quality > 6 {
+		return nil, errors.New("invalid quality setting")
+	}
+	r := Resampler{
+		outRate:     outputRate,
+		quality:     quality,
+		destination: writer,
+		wavMode:     true,
+	}
+	return &r, nil
+}
+
+// NewWAVReader returns a pointer to a ResamplerReader that implements
+// io.Reader. Unlike NewReader, it does not need to be told the input
+// channels, sample rate or sample format: it parses them from the
+// RIFF/WAVE header at the start of src, scanning chunks instead of
+// assuming "fmt " and "data" sit at a fixed offset. Read returns resampled
+// PCM data, not a re-wrapped WAV stream.
+func NewWAVReader(src io.Reader, outputRate float64, quality int) (*ResamplerReader, error) {
+	if src == nil {
+		return nil, errors.New("io.Reader is nil")
+	}
+	var header []byte
+	probe := make([]byte, 64)
+	var format wavFormat
+	var dataOffset int
+	var ok bool
+	for {
+		n, rerr := src.Read(probe)
+		header = append(header, probe[:n]...)
+		var err error
+		format, dataOffset, ok, err = parseWAVChunks(header)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			break
+		}
+		if rerr != nil {
+			if rerr == io.EOF {
+				return nil, errors.New("WAV data chunk not found")
+			}
+			return nil, rerr
+		}
+	}
+	inFormat, err := wavSampleFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	pcm := io.MultiReader(bytes.NewReader(header[dataOffset:]), src)
+	return NewReader(pcm, float64(format.sampleRate), outputRate, int(format.channels), inFormat, quality)
+}
+
+// writeWAVData buffers p until a full RIFF/WAVE header has been seen,
+// configures the resampler from the channels, sample rate and sample
+// format found in the "fmt " chunk, writes a WAV header for the resampled
+// output, and feeds any PCM bytes found past the "data" chunk into the
+// resampler.
+func (r *Resampler) writeWAVData(p []byte) (int, error) {
+	r.wavHeader = append(r.wavHeader, p...)
+	format, dataOffset, ok, err := parseWAVChunks(r.wavHeader)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		// Still waiting for the "fmt " and "data" chunks to show up.
+		return len(p), nil
+	}
+	inFormat, err := wavSampleFormat(format)
+	if err != nil {
+		return 0, err
+	}
+	soxr, size, err := newSoxr(float64(format.sampleRate), r.outRate, int(format.channels), inFormat, r.quality)
+	if err != nil {
+		return 0, err
+	}
+	if err := writeWAVHeader(r.destination, int(format.channels), r.outRate, inFormat); err != nil {
+		return 0, err
+	}
+	r.resampler = soxr
+	r.inRate = float64(format.sampleRate)
+	r.channels = int(format.channels)
+	r.frameSize = size
+	r.wavParsed = true
+	pcm := r.wavHeader[dataOffset:]
+	r.wavHeader = nil
+	if _, err := r.process(pcm); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// parseWAVChunks scans a RIFF/WAVE buffer for the "fmt " and "data" chunks
+// without assuming either sits at a fixed offset. It returns ok=false,
+// with no error, when buf does not yet hold enough bytes to locate the
+// "data" chunk.
+func parseWAVChunks(buf []byte) (format wavFormat, dataOffset int, ok bool, err error) {
+	if len(buf) < riffHeaderSize {
+		return format, 0, false, nil
+	}
+	if string(buf[0:4]) != "RIFF" || string(buf[8:12]) != "WAVE" {
+		return format, 0, false, errors.New("not a valid WAV/RIFF stream")
+	}
+	var haveFmt bool
+	for pos := riffHeaderSize; pos+8 <= len(buf); {
+		id := string(buf[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(buf[pos+4 : pos+8]))
+		body := pos + 8
+		switch id {
+		case "fmt ":
+			if body+fmtChunkSize > len(buf) {
+				return format, 0, false, nil
+			}
+			format.audioFormat = binary.LittleEndian.Uint16(buf[body : body+2])
+			format.channels = binary.LittleEndian.Uint16(buf[body+2 : body+4])
+			format.sampleRate = binary.LittleEndian.Uint32(buf[body+4 : body+8])
+			format.bitsPerSample = binary.LittleEndian.Uint16(buf[body+14 : body+16])
+			haveFmt = true
+		case "data":
+			if !haveFmt {
+				return format, 0, false, errors.New("WAV data chunk found before fmt chunk")
+			}
+			return format, body, true, nil
+		}
+		pos = body + size + size%2 // chunks are padded to an even size
+	}
+	return format, 0, false, nil
+}
+
+// wavSampleFormat maps a WAV "fmt " chunk to the matching resample package
+// format constant.
+func wavSampleFormat(f wavFormat) (int, error) {
+	switch {
+	case f.audioFormat == wavFormatIEEEFloat && f.bitsPerSample == 32:
+		return F32, nil
+	case f.audioFormat == wavFormatIEEEFloat && f.bitsPerSample == 64:
+		return F64, nil
+	case f.audioFormat == wavFormatPCM && f.bitsPerSample == 32:
+		return I32, nil
+	case f.audioFormat == wavFormatPCM && f.bitsPerSample == 16:
+		return I16, nil
+	default:
+		return 0, errors.New("unsupported WAV sample format")
+	}
+}
+
+// writeWAVHeader writes a canonical 44-byte WAV/RIFF header for PCM data at
+// the given channel count, sample rate and resample package format. The
+// RIFF and data chunk sizes are written as zero; Close back-patches them
+// when the destination supports seeking.
+func writeWAVHeader(w io.Writer, channels int, sampleRate float64, format int) error {
+	var bits uint16
+	audioFormat := uint16(wavFormatPCM)
+	switch format {
+	case F64:
+		bits, audioFormat = 64, wavFormatIEEEFloat
+	case F32:
+		bits, audioFormat = 32, wavFormatIEEEFloat
+	case I32:
+		bits = 32
+	case I16:
+		bits = 16
+	default:
+		return errors.New("invalid format setting")
+	}
+	blockAlign := uint16(channels) * bits / byteLen
+	byteRate := uint32(sampleRate) * uint32(blockAlign)
+
+	header := make([]byte, wavHeaderSize)
+	copy(header[0:4], "RIFF")
+	copy(header[8:12], "WAVE")
+	copy(header[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:20], fmtChunkSize)
+	binary.LittleEndian.PutUint16(header[20:22], audioFormat)
+	binary.LittleEndian.PutUint16(header[22:24], uint16(channels))
+	binary.LittleEndian.PutUint32(header[24:28], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(header[28:32], byteRate)
+	binary.LittleEndian.PutUint16(header[32:34], blockAlign)
+	binary.LittleEndian.PutUint16(header[34:36], bits)
+	copy(header[36:40], "data")
+	_, err := w.Write(header)
+	return err
+}
+
+// patchWAVSizes back-patches the RIFF and data chunk size fields of a WAV
+// header written by writeWAVHeader, now that dataBytes of PCM data have
+// been written past it.
+func patchWAVSizes(w io.WriteSeeker, dataBytes int64) error {
+	sizeBuf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sizeBuf, uint32(dataBytes+wavHeaderSize-8))
+	if _, err := w.Seek(4, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := w.Write(sizeBuf); err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(sizeBuf, uint32(dataBytes))
+	if _, err := w.Seek(wavHeaderSize-4, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := w.Write(sizeBuf); err != nil {
+		return err
+	}
+	_, err := w.Seek(0, io.SeekEnd)
+	return err
+}
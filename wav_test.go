@@ -0,0 +1,196 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package resample
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// makeWAV builds a minimal WAV/RIFF buffer with the given fmt chunk fields
+// and PCM payload, optionally padding the fmt chunk with an extra sub-chunk
+// in between to exercise offset-agnostic scanning.
+func makeWAV(channels uint16, sampleRate uint32, bits uint16, audioFormat uint16, extraChunk bool, data []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(fmtChunkSize))
+	binary.Write(&buf, binary.LittleEndian, audioFormat)
+	binary.Write(&buf, binary.LittleEndian, channels)
+	binary.Write(&buf, binary.LittleEndian, sampleRate)
+	binary.Write(&buf, binary.LittleEndian, uint32(0)) // byte rate, unused by the parser
+	binary.Write(&buf, binary.LittleEndian, uint16(0)) // block align, unused by the parser
+	binary.Write(&buf, binary.LittleEndian, bits)
+	if extraChunk {
+		buf.WriteString("JUNK")
+		binary.Write(&buf, binary.LittleEndian, uint32(2))
+		buf.Write([]byte{0x00, 0x00})
+	}
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func TestParseWAVChunks(t *testing.T) {
+	data := []byte{0x01, 0x00, 0x02, 0x00}
+	for _, extra := range []bool{false, true} {
+		wav := makeWAV(2, 16000, 16, wavFormatPCM, extra, data)
+		format, offset, ok, err := parseWAVChunks(wav)
+		if err != nil {
+			t.Fatalf("parseWAVChunks failed (extraChunk=%v): %v", extra, err)
+		}
+		if !ok {
+			t.Fatalf("parseWAVChunks reported not enough data (extraChunk=%v)", extra)
+		}
+		if format.channels != 2 || format.sampleRate != 16000 || format.bitsPerSample != 16 {
+			t.Errorf("parseWAVChunks parsed wrong format: %+v", format)
+		}
+		if !bytes.Equal(wav[offset:], data) {
+			t.Errorf("parseWAVChunks returned wrong data offset (extraChunk=%v)", extra)
+		}
+	}
+}
+
+func TestParseWAVChunksIncomplete(t *testing.T) {
+	wav := makeWAV(1, 8000, 16, wavFormatPCM, false, []byte{0x01, 0x00})
+	_, _, ok, err := parseWAVChunks(wav[:20])
+	if err != nil {
+		t.Fatalf("parseWAVChunks returned an error on a truncated header: %v", err)
+	}
+	if ok {
+		t.Fatal("parseWAVChunks reported completion on a truncated header")
+	}
+}
+
+func TestParseWAVChunksNotRIFF(t *testing.T) {
+	_, _, _, err := parseWAVChunks([]byte("not a wav file at all......."))
+	if err == nil {
+		t.Fatal("parseWAVChunks didn't return an error for a non-RIFF stream")
+	}
+}
+
+func TestWAVHeaderRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeWAVHeader(&buf, 2, 8000.0, I16); err != nil {
+		t.Fatal("writeWAVHeader failed:", err)
+	}
+	format, offset, ok, err := parseWAVChunks(buf.Bytes())
+	if err != nil || !ok {
+		t.Fatalf("failed to parse back the header we just wrote: ok=%v err=%v", ok, err)
+	}
+	if format.channels != 2 || format.sampleRate != 8000 || format.bitsPerSample != 16 || format.audioFormat != wavFormatPCM {
+		t.Errorf("writeWAVHeader produced unexpected fields: %+v", format)
+	}
+	if offset != wavHeaderSize {
+		t.Errorf("writeWAVHeader data offset = %d, expecting %d", offset, wavHeaderSize)
+	}
+}
+
+type seekBuffer struct {
+	bytes.Buffer
+	pos int64
+}
+
+func (s *seekBuffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		s.pos = offset
+	case io.SeekEnd:
+		s.pos = int64(s.Buffer.Len())
+	default:
+		s.pos += offset
+	}
+	return s.pos, nil
+}
+
+func (s *seekBuffer) Write(p []byte) (int, error) {
+	b := s.Buffer.Bytes()
+	if int(s.pos)+len(p) > len(b) {
+		grown := make([]byte, int(s.pos)+len(p))
+		copy(grown, b)
+		s.Buffer = *bytes.NewBuffer(grown)
+		b = s.Buffer.Bytes()
+	}
+	n := copy(b[s.pos:], p)
+	s.pos += int64(n)
+	return n, nil
+}
+
+func TestNewWAV(t *testing.T) {
+	_, err := NewWAV(nil, 8000.0, MediumQ)
+	if err == nil || err.Error() != "io.Writer is nil" {
+		t.Fatalf("Expecting: %s got: %v", "io.Writer is nil", err)
+	}
+	_, err = NewWAV(io.Discard, 0, MediumQ)
+	if err == nil || err.Error() != "invalid output sampling rate" {
+		t.Fatalf("Expecting: %s got: %v", "invalid output sampling rate", err)
+	}
+
+	var out seekBuffer
+	res, err := NewWAV(&out, 8000.0, MediumQ)
+	if err != nil {
+		t.Fatal("Failed to create a WAV Resampler:", err)
+	}
+	data := []byte{0x01, 0x00, 0x7c, 0x7f, 0xd1, 0xd0, 0xd3, 0xd2, 0xdd, 0xdc, 0xdf, 0xde}
+	wav := makeWAV(1, 16000, 16, wavFormatPCM, false, data)
+	if _, err := res.Write(wav); err != nil {
+		t.Fatal("Write failed:", err)
+	}
+	if err := res.Close(); err != nil {
+		t.Fatal("Close failed:", err)
+	}
+	format, offset, ok, err := parseWAVChunks(out.Bytes())
+	if err != nil || !ok {
+		t.Fatalf("output is not a valid WAV stream: ok=%v err=%v", ok, err)
+	}
+	if format.sampleRate != 8000 {
+		t.Errorf("output sample rate = %d, expecting 8000", format.sampleRate)
+	}
+	dataSize := binary.LittleEndian.Uint32(out.Bytes()[wavHeaderSize-4 : wavHeaderSize])
+	if int(dataSize) != len(out.Bytes())-offset {
+		t.Errorf("back-patched data size = %d, expecting %d", dataSize, len(out.Bytes())-offset)
+	}
+}
+
+func TestNewWAVReader(t *testing.T) {
+	_, err := NewWAVReader(nil, 8000.0, MediumQ)
+	if err == nil || err.Error() != "io.Reader is nil" {
+		t.Fatalf("Expecting: %s got: %v", "io.Reader is nil", err)
+	}
+
+	data := []byte{0x01, 0x00, 0x7c, 0x7f, 0xd1, 0xd0, 0xd3, 0xd2, 0xdd, 0xdc, 0xdf, 0xde}
+	wav := makeWAV(1, 16000, 16, wavFormatPCM, false, data)
+	res, err := NewWAVReader(bytes.NewReader(wav), 8000.0, MediumQ)
+	if err != nil {
+		t.Fatal("Failed to create a WAV ResamplerReader:", err)
+	}
+	defer res.Close()
+	var out bytes.Buffer
+	buf := make([]byte, 4)
+	for {
+		n, err := res.Read(buf)
+		out.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Read failed:", err)
+		}
+	}
+	inSize := float64(len(data))
+	outSize := float64(out.Len()) * 16000.0 / 8000.0
+	if inSize != outSize {
+		t.Errorf("NewWAVReader Read returned %d bytes, expecting %d", out.Len(), len(data)/2)
+	}
+}